@@ -0,0 +1,159 @@
+package redact
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeStore is an in-memory Store, so the slug handling, migration, and enable/disable logic
+// in this package can be driven from tests without a real API server. It serves Lister() off
+// the same cache.Indexer a real informer would populate, so the Redactor methods under test
+// exercise the same read path they do in production.
+type fakeStore struct {
+	namespace string
+
+	mu           sync.Mutex
+	indexer      cache.Indexer
+	configMap    *v1.ConfigMap
+	resourceVers int
+}
+
+func newFakeStore(namespace string) *fakeStore {
+	return &fakeStore{
+		namespace: namespace,
+		indexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		}),
+	}
+}
+
+func (s *fakeStore) Lister() corelisters.ConfigMapLister {
+	return corelisters.NewConfigMapLister(s.indexer)
+}
+
+func (s *fakeStore) Namespace() string {
+	return s.namespace
+}
+
+func (s *fakeStore) nextResourceVersion() string {
+	s.resourceVers++
+	return strconv.Itoa(s.resourceVers)
+}
+
+func (s *fakeStore) Get() (*v1.ConfigMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configMap == nil {
+		return nil, kuberneteserrors.NewNotFound(v1.Resource("configmaps"), configMapName)
+	}
+	return s.configMap.DeepCopy(), nil
+}
+
+func (s *fakeStore) Create() (*v1.ConfigMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configMap != nil {
+		return nil, kuberneteserrors.NewAlreadyExists(v1.Resource("configmaps"), configMapName)
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            configMapName,
+			Namespace:       s.namespace,
+			ResourceVersion: s.nextResourceVersion(),
+		},
+		Data: map[string]string{},
+	}
+	if err := s.indexer.Add(configMap); err != nil {
+		return nil, err
+	}
+	s.configMap = configMap.DeepCopy()
+	return configMap.DeepCopy(), nil
+}
+
+func (s *fakeStore) Update(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configMap == nil {
+		return nil, kuberneteserrors.NewNotFound(v1.Resource("configmaps"), configMapName)
+	}
+
+	updated := configMap.DeepCopy()
+	updated.ResourceVersion = s.nextResourceVersion()
+	if err := s.indexer.Update(updated); err != nil {
+		return nil, err
+	}
+	s.configMap = updated.DeepCopy()
+	return updated.DeepCopy(), nil
+}
+
+func (s *fakeStore) Patch(pt types.PatchType, data []byte) (*v1.ConfigMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configMap == nil {
+		return nil, kuberneteserrors.NewNotFound(v1.Resource("configmaps"), configMapName)
+	}
+
+	if pt != types.StrategicMergePatchType {
+		return nil, errors.Errorf("fakeStore: unsupported patch type %s", pt)
+	}
+
+	// A patch that carries a resourceVersion precondition must match what's currently stored,
+	// the same way a real API server rejects a patch computed against a configmap that's since
+	// moved on, so tests can actually exercise the conflict-retry path instead of every patch
+	// silently applying against whatever the live state happens to be.
+	var preconditions struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &preconditions); err != nil {
+		return nil, errors.Wrap(err, "unmarshal patch preconditions")
+	}
+	if rv := preconditions.Metadata.ResourceVersion; rv != "" && rv != s.configMap.ResourceVersion {
+		return nil, kuberneteserrors.NewConflict(v1.Resource("configmaps"), configMapName,
+			errors.Errorf("resourceVersion mismatch: patch was computed against %q, current is %q", rv, s.configMap.ResourceVersion))
+	}
+
+	original, err := json.Marshal(s.configMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal original configmap")
+	}
+
+	mergedJSON, err := strategicpatch.StrategicMergePatch(original, data, v1.ConfigMap{})
+	if err != nil {
+		return nil, errors.Wrap(err, "apply strategic merge patch")
+	}
+
+	var patched v1.ConfigMap
+	if err := json.Unmarshal(mergedJSON, &patched); err != nil {
+		return nil, errors.Wrap(err, "unmarshal patched configmap")
+	}
+
+	patched.ResourceVersion = s.nextResourceVersion()
+	if err := s.indexer.Update(&patched); err != nil {
+		return nil, err
+	}
+	s.configMap = patched.DeepCopy()
+	return patched.DeepCopy(), nil
+}
+
+// newRedactorWithStore builds a Redactor against an arbitrary Store, used in tests to drive
+// the business logic without a real cluster.
+func newRedactorWithStore(store Store) *Redactor {
+	return &Redactor{store: store}
+}
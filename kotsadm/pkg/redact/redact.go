@@ -3,9 +3,9 @@ package redact
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -16,8 +16,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/util/retry"
 )
 
 func init() {
@@ -39,14 +40,72 @@ type RedactorMetadata struct {
 	Redact v1beta1.Redact `json:"redact"`
 }
 
+// Redactor holds the redactor business logic (slug handling, migration, enable/disable)
+// on top of a Store, decoupling it from the concrete Kubernetes wiring.
+type Redactor struct {
+	store Store
+
+	parseMu  sync.Mutex
+	parsedRV string
+	parsed   *v1beta1.Redactor
+}
+
+// NewRedactor returns a Redactor backed by a fresh Kubernetes clientset and its own informer.
+// Most callers want the shared, process-wide instance from defaultRedactor instead: a Redactor
+// built by this constructor owns a SharedInformerFactory that runs for as long as the Redactor
+// is reachable, so constructing one per call leaks a watch and a goroutine per call.
+func NewRedactor() (*Redactor, error) {
+	store, err := newKubeStore()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kube store")
+	}
+	return &Redactor{store: store}, nil
+}
+
+var (
+	defaultRedactorMu  sync.Mutex
+	defaultRedactorVal *Redactor
+)
+
+// defaultRedactor returns the single process-wide Redactor that every package-level function
+// in this file shares, so the informer cache it reads through - and the parsed-spec memoization
+// on top of it - actually persist across calls instead of being rebuilt, and leaked, on every
+// request. Construction failures are not memoized: if NewRedactor fails (e.g. a transient
+// API-server hiccup at startup), the next call retries from scratch instead of returning the
+// same stale error for the rest of the process's life.
+func defaultRedactor() (*Redactor, error) {
+	defaultRedactorMu.Lock()
+	defer defaultRedactorMu.Unlock()
+
+	if defaultRedactorVal != nil {
+		return defaultRedactorVal, nil
+	}
+
+	r, err := NewRedactor()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultRedactorVal = r
+	return defaultRedactorVal, nil
+}
+
 // GetRedactSpec returns the redaction yaml spec, a pretty error string, and the underlying error
 func GetRedactSpec() (string, string, error) {
-	configMap, errstr, err := getConfigmap()
+	r, err := defaultRedactor()
+	if err != nil {
+		return "", "failed to initialize redactor", err
+	}
+	return r.GetRedactSpec()
+}
+
+func (r *Redactor) GetRedactSpec() (string, string, error) {
+	configMap, errstr, err := r.getConfigmap()
 	if err != nil || configMap == nil {
 		return "", errstr, err
 	}
 
-	redactObj, err := buildFullRedact(configMap)
+	redactObj, err := r.buildFullRedactCached(configMap)
 	if err != nil {
 		return "", "failed to build full redact yaml", err
 	}
@@ -59,7 +118,15 @@ func GetRedactSpec() (string, string, error) {
 }
 
 func GetRedact() (*v1beta1.Redactor, error) {
-	configmap, _, err := getConfigmap()
+	r, err := defaultRedactor()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRedact()
+}
+
+func (r *Redactor) GetRedact() (*v1beta1.Redactor, error) {
+	configmap, _, err := r.getConfigmap()
 	if err != nil {
 		return nil, err
 	}
@@ -67,11 +134,19 @@ func GetRedact() (*v1beta1.Redactor, error) {
 		return nil, nil
 	}
 
-	return buildFullRedact(configmap)
+	return r.buildFullRedactCached(configmap)
 }
 
 func GetRedactInfo() ([]RedactorList, error) {
-	configmap, _, err := getConfigmap()
+	r, err := defaultRedactor()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRedactInfo()
+}
+
+func (r *Redactor) GetRedactInfo() ([]RedactorList, error) {
+	configmap, _, err := r.getConfigmap()
 	if err != nil {
 		return nil, errors.Wrap(err, "get redactors configmap")
 	}
@@ -79,16 +154,21 @@ func GetRedactInfo() ([]RedactorList, error) {
 		return nil, nil
 	}
 
-	if combinedYaml, ok := configmap.Data["kotsadm-redact"]; ok {
+	if _, ok := configmap.Data["kotsadm-redact"]; ok {
 		// this is the key used for the combined redact list, so run the migration
-		newMap, err := splitRedactors(combinedYaml, configmap.Data)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to split combined redactors")
-		}
-		configmap.Data = newMap
+		configmap, err = r.writeConfigmap(func(configMap *v1.ConfigMap) error {
+			combinedYaml, ok := configMap.Data["kotsadm-redact"]
+			if !ok {
+				return nil
+			}
 
-		// now that the redactors have been split, save the configmap
-		configmap, err = writeConfigmap(configmap)
+			newMap, err := splitRedactors(combinedYaml, configMap.Data)
+			if err != nil {
+				return errors.Wrap(err, "failed to split combined redactors")
+			}
+			configMap.Data = newMap
+			return nil
+		})
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to update configmap")
 		}
@@ -108,7 +188,15 @@ func GetRedactInfo() ([]RedactorList, error) {
 }
 
 func GetRedactBySlug(slug string) (*RedactorMetadata, error) {
-	configmap, _, err := getConfigmap()
+	r, err := defaultRedactor()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRedactBySlug(slug)
+}
+
+func (r *Redactor) GetRedactBySlug(slug string) (*RedactorMetadata, error) {
+	configmap, _, err := r.getConfigmap()
 	if err != nil {
 		return nil, err
 	}
@@ -132,28 +220,22 @@ func GetRedactBySlug(slug string) (*RedactorMetadata, error) {
 
 // SetRedactSpec sets the global redact spec to the specified string, and returns a pretty error string + the underlying error
 func SetRedactSpec(spec string) (string, error) {
-	cfg, err := config.GetConfig()
+	r, err := defaultRedactor()
 	if err != nil {
-		return "failed to get cluster config", errors.Wrap(err, "failed to get cluster config")
-	}
-
-	clientset, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return "failed to create kubernetes clientset", errors.Wrap(err, "failed to create kubernetes clientset")
-	}
-
-	configMap, errMsg, err := getConfigmap()
-	if err != nil {
-		return errMsg, err
-	}
-
-	newMap, err := splitRedactors(spec, configMap.Data)
-	if err != nil {
-		return "failed to split redactors", errors.Wrap(err, "failed to split redactors")
+		return "failed to initialize redactor", err
 	}
+	return r.SetRedactSpec(spec)
+}
 
-	configMap.Data = newMap
-	_, err = clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Update(configMap)
+func (r *Redactor) SetRedactSpec(spec string) (string, error) {
+	_, err := r.writeConfigmap(func(configMap *v1.ConfigMap) error {
+		newMap, err := splitRedactors(spec, configMap.Data)
+		if err != nil {
+			return errors.Wrap(err, "failed to split redactors")
+		}
+		configMap.Data = newMap
+		return nil
+	})
 	if err != nil {
 		return "failed to update kotsadm-redact configMap", errors.Wrap(err, "failed to update kotsadm-redact configMap")
 	}
@@ -162,6 +244,14 @@ func SetRedactSpec(spec string) (string, error) {
 
 // updates/creates an individual redact with the provided metadata and yaml
 func SetRedactYaml(name, slug, description string, enabled, newRedact bool, yamlBytes []byte) (*RedactorMetadata, error) {
+	r, err := defaultRedactor()
+	if err != nil {
+		return nil, err
+	}
+	return r.SetRedactYaml(name, slug, description, enabled, newRedact, yamlBytes)
+}
+
+func (r *Redactor) SetRedactYaml(name, slug, description string, enabled, newRedact bool, yamlBytes []byte) (*RedactorMetadata, error) {
 	// parse yaml as redactor
 	newRedactorSpec := v1beta1.Redact{}
 	err := yaml.Unmarshal(yamlBytes, &newRedactorSpec)
@@ -169,87 +259,90 @@ func SetRedactYaml(name, slug, description string, enabled, newRedact bool, yaml
 		return nil, errors.Wrapf(err, "unable to parse new redact yaml")
 	}
 
-	configMap, _, err := getConfigmap()
-	if err != nil {
-		return nil, err
-	}
+	// redactorEntry and slug are recomputed from scratch on every retry attempt below,
+	// since they depend on the freshly-fetched configmap contents
+	var redactorEntry RedactorMetadata
 
-	if configMap.Data == nil {
-		configMap.Data = map[string]string{}
-	}
+	_, err = r.writeConfigmap(func(configMap *v1.ConfigMap) error {
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
 
-	redactorEntry := RedactorMetadata{}
-	redactString, ok := configMap.Data[slug]
-	if !ok || newRedact {
-		// if name is not set in yaml or the request, take the name from the slug
-		// if name is set, create the slug from the name
-		if newRedactorSpec.Name == "" && name == "" {
-			newRedactorSpec.Name = slug
-		} else {
-			// name in request overrides name in yaml
-			if name != "" {
-				newRedactorSpec.Name = name
+		spec := newRedactorSpec
+		entrySlug := slug
+
+		redactorEntry = RedactorMetadata{}
+		redactString, ok := configMap.Data[entrySlug]
+		if !ok || newRedact {
+			// if name is not set in yaml or the request, take the name from the slug
+			// if name is set, create the slug from the name
+			if spec.Name == "" && name == "" {
+				spec.Name = entrySlug
+			} else {
+				// name in request overrides name in yaml
+				if name != "" {
+					spec.Name = name
+				}
+				entrySlug = getSlug(spec.Name)
 			}
-			slug = getSlug(newRedactorSpec.Name)
-		}
 
-		if _, ok := configMap.Data[slug]; ok {
-			// the target slug already exists - this is an error
-			return nil, fmt.Errorf("refusing to create new redact spec with name %s - slug %s already exists", newRedactorSpec.Name, slug)
-		}
+			if _, ok := configMap.Data[entrySlug]; ok {
+				// the target slug already exists - this is an error
+				return fmt.Errorf("refusing to create new redact spec with name %s - slug %s already exists", spec.Name, entrySlug)
+			}
 
-		// create the new redactor
-		redactorEntry.Metadata = RedactorList{
-			Name:    newRedactorSpec.Name,
-			Slug:    slug,
-			Created: time.Now(),
-		}
-	} else {
-		// unmarshal existing redactor, check if name changed
-		err = json.Unmarshal([]byte(redactString), &redactorEntry)
-		if err != nil {
-			return nil, errors.Wrapf(err, "unable to parse redactor %s", slug)
-		}
+			// create the new redactor
+			redactorEntry.Metadata = RedactorList{
+				Name:    spec.Name,
+				Slug:    entrySlug,
+				Created: time.Now(),
+			}
+		} else {
+			// unmarshal existing redactor, check if name changed
+			err := json.Unmarshal([]byte(redactString), &redactorEntry)
+			if err != nil {
+				return errors.Wrapf(err, "unable to parse redactor %s", entrySlug)
+			}
 
-		// name in request overrides name in spec
-		if name != newRedactorSpec.Name && name != "" {
-			newRedactorSpec.Name = name
-		}
+			// name in request overrides name in spec
+			if name != spec.Name && name != "" {
+				spec.Name = name
+			}
 
-		if slug != getSlug(newRedactorSpec.Name) && newRedactorSpec.Name != "" {
-			// changing name
+			if entrySlug != getSlug(spec.Name) && spec.Name != "" {
+				// changing name
 
-			if _, ok := configMap.Data[getSlug(newRedactorSpec.Name)]; ok {
-				// the target slug already exists - this is an error
-				return nil, fmt.Errorf("refusing to change slug from %s to %s as that already exists", slug, getSlug(newRedactorSpec.Name))
-			}
+				if _, ok := configMap.Data[getSlug(spec.Name)]; ok {
+					// the target slug already exists - this is an error
+					return fmt.Errorf("refusing to change slug from %s to %s as that already exists", entrySlug, getSlug(spec.Name))
+				}
 
-			delete(configMap.Data, slug)
-			slug = getSlug(newRedactorSpec.Name)
-			redactorEntry.Metadata.Slug = slug
-			redactorEntry.Metadata.Name = newRedactorSpec.Name
-		}
+				delete(configMap.Data, entrySlug)
+				entrySlug = getSlug(spec.Name)
+				redactorEntry.Metadata.Slug = entrySlug
+				redactorEntry.Metadata.Name = spec.Name
+			}
 
-		if newRedactorSpec.Name == "" {
-			newRedactorSpec.Name = slug
-			redactorEntry.Metadata.Name = slug
+			if spec.Name == "" {
+				spec.Name = entrySlug
+				redactorEntry.Metadata.Name = entrySlug
+			}
 		}
-	}
-
-	redactorEntry.Metadata.Enabled = enabled
-	redactorEntry.Metadata.Description = description
-	redactorEntry.Metadata.Updated = time.Now()
 
-	redactorEntry.Redact = newRedactorSpec
+		redactorEntry.Metadata.Enabled = enabled
+		redactorEntry.Metadata.Description = description
+		redactorEntry.Metadata.Updated = time.Now()
 
-	jsonBytes, err := json.Marshal(redactorEntry)
-	if err != nil {
-		return nil, errors.Wrapf(err, "unable to marshal redactor %s", slug)
-	}
+		redactorEntry.Redact = spec
 
-	configMap.Data[slug] = string(jsonBytes)
+		jsonBytes, err := json.Marshal(redactorEntry)
+		if err != nil {
+			return errors.Wrapf(err, "unable to marshal redactor %s", entrySlug)
+		}
 
-	_, err = writeConfigmap(configMap)
+		configMap.Data[entrySlug] = string(jsonBytes)
+		return nil
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "write configMap with updated redact")
 	}
@@ -257,79 +350,148 @@ func SetRedactYaml(name, slug, description string, enabled, newRedact bool, yaml
 }
 
 func DeleteRedact(slug string) error {
-	configMap, _, err := getConfigmap()
+	r, err := defaultRedactor()
 	if err != nil {
 		return err
 	}
+	return r.DeleteRedact(slug)
+}
 
-	delete(configMap.Data, slug)
-
-	_, err = writeConfigmap(configMap)
+func (r *Redactor) DeleteRedact(slug string) error {
+	_, err := r.writeConfigmap(func(configMap *v1.ConfigMap) error {
+		delete(configMap.Data, slug)
+		return nil
+	})
 	if err != nil {
 		return errors.Wrapf(err, "write configMap with updated redact")
 	}
 	return nil
 }
 
-func getConfigmap() (*v1.ConfigMap, string, error) {
-	cfg, err := config.GetConfig()
+// getConfigmap serves reads from the store's informer-backed lister rather than hitting the
+// API server, since this is called on hot paths like support-bundle generation and redactor
+// listing.
+func (r *Redactor) getConfigmap() (*v1.ConfigMap, string, error) {
+	configMap, err := r.store.Lister().ConfigMaps(r.store.Namespace()).Get(configMapName)
 	if err != nil {
-		return nil, "failed to get cluster config", errors.Wrap(err, "failed to get cluster config")
+		if kuberneteserrors.IsNotFound(err) {
+			created, err := r.store.Create()
+			if err != nil {
+				return nil, "failed to create kotsadm-redact configMap", err
+			}
+			return created, "", nil
+		}
+		return nil, "failed to get kotsadm-redact configMap", err
 	}
+	return configMap, "", nil
+}
 
-	clientset, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return nil, "failed to create kubernetes clientset", errors.Wrap(err, "failed to create kubernetes clientset")
+// buildFullRedactCached memoizes the parsed Redactor spec for a given configmap
+// resourceVersion, so repeated reads between informer updates don't re-parse every key.
+func (r *Redactor) buildFullRedactCached(configMap *v1.ConfigMap) (*v1beta1.Redactor, error) {
+	r.parseMu.Lock()
+	if r.parsed != nil && r.parsedRV == configMap.ResourceVersion {
+		defer r.parseMu.Unlock()
+		return r.parsed, nil
 	}
+	r.parseMu.Unlock()
 
-	configMap, err := clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Get("kotsadm-redact", metav1.GetOptions{})
+	full, err := buildFullRedact(configMap)
 	if err != nil {
-		if !kuberneteserrors.IsNotFound(err) {
-			// not a not found error, so a real error
-			return nil, "failed to get kotsadm-redact configMap", errors.Wrap(err, "failed to get kotsadm-redact configMap")
-		} else {
-			// not found, so create one and return it
-			newMap := v1.ConfigMap{
-				TypeMeta: metav1.TypeMeta{
-					Kind:       "ConfigMap",
-					APIVersion: "v1",
-				},
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "kotsadm-redact",
-					Namespace: os.Getenv("POD_NAMESPACE"),
-					Labels: map[string]string{
-						"kots.io/kotsadm": "true",
-					},
-				},
-				Data: map[string]string{},
+		return nil, err
+	}
+
+	r.parseMu.Lock()
+	r.parsedRV = configMap.ResourceVersion
+	r.parsed = full
+	r.parseMu.Unlock()
+
+	return full, nil
+}
+
+// writeConfigmap applies mutate to a freshly fetched copy of the kotsadm-redact configmap
+// and writes back only the changes mutate made, as a strategic merge patch. This keeps two
+// concurrent editors (the admin console, kubectl, the splitRedactors migration) from
+// clobbering each other's keys the way a full Update would. Unlike the read paths elsewhere in
+// this file, the base copy is fetched live rather than through the informer's Lister: the patch
+// carries the fetched resourceVersion as an explicit precondition, so a conflicting concurrent
+// write is rejected instead of silently clobbered, and that only works if the resourceVersion
+// is actually current. The whole get-mutate-patch cycle is retried on conflict, with mutate
+// re-run against the latest configmap each attempt.
+func (r *Redactor) writeConfigmap(mutate func(configMap *v1.ConfigMap) error) (*v1.ConfigMap, error) {
+	var updated *v1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := r.store.Get()
+		if err != nil {
+			if kuberneteserrors.IsNotFound(errors.Cause(err)) {
+				configMap, err = r.store.Create()
 			}
-			createdMap, err := clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Create(&newMap)
 			if err != nil {
-				return nil, "failed to create kotsadm-redact configMap", errors.Wrap(err, "failed to create kotsadm-redact configMap")
+				return err
 			}
+		}
 
-			return createdMap, "", nil
+		originalJSON, err := json.Marshal(configMap)
+		if err != nil {
+			return errors.Wrap(err, "marshal original configmap")
+		}
+
+		modified := configMap.DeepCopy()
+		if err := mutate(modified); err != nil {
+			return err
 		}
-	}
-	return configMap, "", nil
-}
 
-func writeConfigmap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
-	cfg, err := config.GetConfig()
+		modifiedJSON, err := json.Marshal(modified)
+		if err != nil {
+			return errors.Wrap(err, "marshal modified configmap")
+		}
+
+		patchBytes, err := strategicpatch.CreateTwoWayMergePatch(originalJSON, modifiedJSON, v1.ConfigMap{})
+		if err != nil {
+			return errors.Wrap(err, "create two way merge patch")
+		}
+
+		if string(patchBytes) == "{}" {
+			// nothing changed, nothing to write
+			updated = modified
+			return nil
+		}
+
+		patchBytes, err = withResourceVersionPrecondition(patchBytes, configMap.ResourceVersion)
+		if err != nil {
+			return errors.Wrap(err, "add resourceVersion precondition to patch")
+		}
+
+		updated, err = r.store.Patch(types.StrategicMergePatchType, patchBytes)
+		// RetryOnConflict only recognizes an unwrapped API status error as a conflict
+		return errors.Cause(err)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get cluster config")
+		return nil, errors.Wrap(err, "failed to patch kotsadm-redact configmap")
 	}
 
-	clientset, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	return updated, nil
+}
+
+// withResourceVersionPrecondition folds metadata.resourceVersion into a strategic merge patch
+// document. CreateTwoWayMergePatch only emits fields that differ between the original and
+// modified objects, so a patch built from a DeepCopy never mentions resourceVersion on its own -
+// without it, the patch applies against whatever the configmap's current state happens to be,
+// and the conflict it's meant to detect never surfaces.
+func withResourceVersionPrecondition(patchBytes []byte, resourceVersion string) ([]byte, error) {
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(patchBytes, &patch); err != nil {
+		return nil, err
 	}
 
-	newConfigMap, err := clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Update(configMap)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to update configmap")
+	metadata, _ := patch["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
 	}
-	return newConfigMap, nil
+	metadata["resourceVersion"] = resourceVersion
+	patch["metadata"] = metadata
+
+	return json.Marshal(patch)
 }
 
 func getSlug(name string) string {
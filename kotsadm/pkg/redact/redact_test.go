@@ -0,0 +1,196 @@
+package redact
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const combinedRedactYaml = `
+apiVersion: troubleshoot.replicated.com/v1beta1
+kind: Redactor
+metadata:
+  name: kotsadm-redact
+spec:
+  redactors:
+  - name: foo
+  - name: bar
+`
+
+func TestGetRedactInfo_CombinedKeyMigration(t *testing.T) {
+	store := newFakeStore("default")
+	r := newRedactorWithStore(store)
+
+	if _, err := store.Create(); err != nil {
+		t.Fatalf("create configmap: %v", err)
+	}
+	configMap, err := store.Lister().ConfigMaps(store.Namespace()).Get(configMapName)
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	configMap = configMap.DeepCopy()
+	configMap.Data["kotsadm-redact"] = combinedRedactYaml
+	if _, err := store.Update(configMap); err != nil {
+		t.Fatalf("seed combined configmap: %v", err)
+	}
+
+	list, err := r.GetRedactInfo()
+	if err != nil {
+		t.Fatalf("GetRedactInfo: %v", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("expected 2 redactors after migration, got %d: %+v", len(list), list)
+	}
+
+	names := map[string]bool{}
+	for _, entry := range list {
+		names[entry.Name] = true
+	}
+	if !names["foo"] || !names["bar"] {
+		t.Fatalf("expected migrated redactors named foo and bar, got %+v", list)
+	}
+
+	migrated, _, err := r.getConfigmap()
+	if err != nil {
+		t.Fatalf("getConfigmap: %v", err)
+	}
+	if _, ok := migrated.Data["kotsadm-redact"]; ok {
+		t.Fatalf("expected combined kotsadm-redact key to be removed after migration")
+	}
+}
+
+func TestSetRedactYaml_SlugRenameCollision(t *testing.T) {
+	store := newFakeStore("default")
+	r := newRedactorWithStore(store)
+
+	if _, err := store.Create(); err != nil {
+		t.Fatalf("create configmap: %v", err)
+	}
+
+	if _, err := r.SetRedactYaml("taken", "taken", "", true, true, []byte(`name: taken`)); err != nil {
+		t.Fatalf("create first redactor: %v", err)
+	}
+	if _, err := r.SetRedactYaml("movable", "movable", "", true, true, []byte(`name: movable`)); err != nil {
+		t.Fatalf("create second redactor: %v", err)
+	}
+
+	// renaming "movable" to the already-taken slug must fail, not clobber the existing entry
+	_, err := r.SetRedactYaml("taken", "movable", "", true, false, []byte(`name: taken`))
+	if err == nil {
+		t.Fatalf("expected an error renaming to a slug that already exists")
+	}
+
+	entry, err := r.GetRedactBySlug("taken")
+	if err != nil {
+		t.Fatalf("GetRedactBySlug(taken): %v", err)
+	}
+	if entry.Metadata.Name != "taken" {
+		t.Fatalf("expected original 'taken' redactor to be untouched, got %+v", entry.Metadata)
+	}
+}
+
+// racingStore wraps fakeStore and injects a concurrent write the first time writeConfigmap
+// fetches its base copy, so a test can exercise the conflict-and-retry path the same way a
+// second real writer racing on the same configmap would.
+type racingStore struct {
+	*fakeStore
+
+	racesRemaining int
+	getCalls       int
+}
+
+func (s *racingStore) Get() (*v1.ConfigMap, error) {
+	s.getCalls++
+
+	configMap, err := s.fakeStore.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.racesRemaining > 0 {
+		s.racesRemaining--
+
+		racer := configMap.DeepCopy()
+		racer.Data["racer"] = "true"
+		if _, err := s.fakeStore.Update(racer); err != nil {
+			return nil, err
+		}
+	}
+
+	return configMap, nil
+}
+
+func TestWriteConfigmap_RetriesOnConcurrentWrite(t *testing.T) {
+	store := &racingStore{fakeStore: newFakeStore("default"), racesRemaining: 1}
+	r := newRedactorWithStore(store)
+
+	if _, err := store.Create(); err != nil {
+		t.Fatalf("create configmap: %v", err)
+	}
+
+	if _, err := r.SetRedactYaml("slug", "slug", "", true, true, []byte(`name: slug`)); err != nil {
+		t.Fatalf("expected the conflicting write to be retried rather than fail, got: %v", err)
+	}
+
+	if store.getCalls < 2 {
+		t.Fatalf("expected writeConfigmap to re-fetch and retry after a conflict, got %d Get call(s)", store.getCalls)
+	}
+
+	configMap, err := store.Get()
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	if configMap.Data["racer"] != "true" {
+		t.Fatalf("expected the concurrent writer's change to survive the retried patch, got %+v", configMap.Data)
+	}
+
+	entry, err := r.GetRedactBySlug("slug")
+	if err != nil {
+		t.Fatalf("GetRedactBySlug: %v", err)
+	}
+	if entry.Metadata.Name != "slug" {
+		t.Fatalf("expected this writer's own change to also have landed, got %+v", entry.Metadata)
+	}
+}
+
+func TestSetRedactYaml_EnableDisableToggle(t *testing.T) {
+	store := newFakeStore("default")
+	r := newRedactorWithStore(store)
+
+	if _, err := store.Create(); err != nil {
+		t.Fatalf("create configmap: %v", err)
+	}
+
+	if _, err := r.SetRedactYaml("toggle", "toggle", "", true, true, []byte(`name: toggle`)); err != nil {
+		t.Fatalf("create redactor: %v", err)
+	}
+
+	entry, err := r.GetRedactBySlug("toggle")
+	if err != nil {
+		t.Fatalf("GetRedactBySlug: %v", err)
+	}
+	if !entry.Metadata.Enabled {
+		t.Fatalf("expected redactor to be enabled after creation")
+	}
+
+	if _, err := r.SetRedactYaml("toggle", "toggle", "", false, false, []byte(`name: toggle`)); err != nil {
+		t.Fatalf("disable redactor: %v", err)
+	}
+
+	entry, err = r.GetRedactBySlug("toggle")
+	if err != nil {
+		t.Fatalf("GetRedactBySlug after disable: %v", err)
+	}
+	if entry.Metadata.Enabled {
+		t.Fatalf("expected redactor to be disabled")
+	}
+
+	full, err := r.GetRedact()
+	if err != nil {
+		t.Fatalf("GetRedact: %v", err)
+	}
+	if len(full.Spec.Redactors) != 0 {
+		t.Fatalf("expected disabled redactor to be excluded from the full spec, got %+v", full.Spec.Redactors)
+	}
+}
@@ -0,0 +1,219 @@
+package redact
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const configMapName = "kotsadm-redact"
+
+// resyncPeriod is how often the informer relists, as a backstop against missed watch events.
+const resyncPeriod = 10 * time.Minute
+
+// catchUpTimeout bounds how long a write waits for the informer cache to observe it before
+// giving up and returning an error, rather than blocking forever on a stalled watch.
+const catchUpTimeout = 5 * time.Second
+
+// Store is the persistence boundary for the kotsadm-redact configmap. It exists so the
+// slug handling, migration, and enable/disable logic in this package can be unit tested
+// without a real API server, and so reads can be served from a warm cache instead of the
+// API server on every call.
+type Store interface {
+	// Lister serves cached reads of the kotsadm-redact configmap.
+	Lister() corelisters.ConfigMapLister
+	// Namespace is the namespace the configmap lives in.
+	Namespace() string
+	// Get fetches the configmap directly from the API server, bypassing the informer cache, so
+	// a caller that needs a genuinely current resourceVersion to detect a write conflict against
+	// (rather than whatever the cache last observed) doesn't widen its own race window.
+	Get() (*v1.ConfigMap, error)
+	// Create creates an empty kotsadm-redact configmap.
+	Create() (*v1.ConfigMap, error)
+	// Update replaces the whole configmap.
+	Update(configMap *v1.ConfigMap) (*v1.ConfigMap, error)
+	// Patch applies a patch of the given type to the configmap and returns the result.
+	Patch(pt types.PatchType, data []byte) (*v1.ConfigMap, error)
+}
+
+// kubeStore is a Store backed by a SharedInformerFactory scoped to the single kotsadm-redact
+// configmap, so the dozens of reads a support-bundle run or a redactor listing used to cost
+// are served from an in-memory cache instead. Writes still go through the API server, and then
+// block until the informer has observed the resourceVersion they produced, so a caller that
+// writes and immediately reads back always sees its own write.
+type kubeStore struct {
+	clientset kubernetes.Interface
+	namespace string
+
+	informerFactory informers.SharedInformerFactory
+	lister          corelisters.ConfigMapLister
+
+	mu       sync.Mutex
+	latestRV string
+}
+
+func newKubeStore() (*kubeStore, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", configMapName).String()
+		}),
+	)
+
+	configMaps := factory.Core().V1().ConfigMaps()
+
+	s := &kubeStore{
+		clientset:       clientset,
+		namespace:       namespace,
+		informerFactory: factory,
+		lister:          configMaps.Lister(),
+	}
+
+	configMaps.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.observe,
+		UpdateFunc: func(_, obj interface{}) { s.observe(obj) },
+		DeleteFunc: s.observe,
+	})
+
+	stopCh := make(chan struct{})
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	return s, nil
+}
+
+// observe records the resourceVersion of every add/update/delete the informer sees, so
+// writers can tell when their own write has landed in the cache.
+func (s *kubeStore) observe(obj interface{}) {
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		configMap, ok = tombstone.Obj.(*v1.ConfigMap)
+		if !ok {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.latestRV = configMap.ResourceVersion
+	s.mu.Unlock()
+}
+
+func (s *kubeStore) Lister() corelisters.ConfigMapLister {
+	return s.lister
+}
+
+func (s *kubeStore) Namespace() string {
+	return s.namespace
+}
+
+func (s *kubeStore) Get() (*v1.ConfigMap, error) {
+	configMap, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(configMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get kotsadm-redact configmap")
+	}
+	return configMap, nil
+}
+
+func (s *kubeStore) Create() (*v1.ConfigMap, error) {
+	newMap := v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName,
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				"kots.io/kotsadm": "true",
+			},
+		},
+		Data: map[string]string{},
+	}
+
+	createdMap, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Create(&newMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kotsadm-redact configmap")
+	}
+
+	if err := s.waitForResourceVersion(createdMap.ResourceVersion); err != nil {
+		return nil, err
+	}
+	return createdMap, nil
+}
+
+func (s *kubeStore) Update(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	updated, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Update(configMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update kotsadm-redact configmap")
+	}
+
+	if err := s.waitForResourceVersion(updated.ResourceVersion); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *kubeStore) Patch(pt types.PatchType, data []byte) (*v1.ConfigMap, error) {
+	patched, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Patch(configMapName, pt, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to patch kotsadm-redact configmap")
+	}
+
+	if err := s.waitForResourceVersion(patched.ResourceVersion); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// waitForResourceVersion blocks until the informer has observed a resourceVersion at least as
+// new as rv, giving callers read-your-writes semantics against the cached lister.
+func (s *kubeStore) waitForResourceVersion(rv string) error {
+	target, err := strconv.ParseInt(rv, 10, 64)
+	if err != nil {
+		// not a comparable resourceVersion, nothing we can wait on
+		return nil
+	}
+
+	deadline := time.Now().Add(catchUpTimeout)
+	for {
+		s.mu.Lock()
+		observed, err := strconv.ParseInt(s.latestRV, 10, 64)
+		s.mu.Unlock()
+		if err == nil && observed >= target {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("timed out waiting for kotsadm-redact cache to catch up to resourceVersion %s", rv)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
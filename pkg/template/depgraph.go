@@ -4,12 +4,26 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 )
 
+// CycleError is returned by TopoSort when the graph cannot be fully ordered because some
+// nodes form a dependency cycle. Cycle holds one minimal cycle, listed in dependency order
+// with the starting node repeated at the end (e.g. ["a", "b", "a"]). TopoSort also returns a
+// CycleError when the unordered nodes only wait on a dependency that is not itself a node of the
+// graph; Cycle is then empty.
+type CycleError struct {
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("cyclical dependency detected: %s", strings.Join(e.Cycle, " -> "))
+}
+
 type depGraph struct {
 	Dependencies map[string]map[string]struct{}
 }
@@ -63,21 +77,252 @@ func (d *depGraph) GetHeadNodes() ([]string, error) {
 	}
 
 	if len(headNodes) == 0 && len(d.Dependencies) != 0 {
-		waitList := []string{}
-		for k, v := range d.Dependencies {
-			depsList := []string{}
-			for dep, _ := range v {
-				depsList = append(depsList, fmt.Sprintf("%q", dep))
+		cycles := findCycles(d.Dependencies, false)
+
+		inCycle := make(map[string]bool)
+		for _, cycle := range cycles {
+			for _, node := range cycle {
+				inCycle[node] = true
 			}
-			waitItem := fmt.Sprintf(`%q depends on %s`, k, strings.Join(depsList, `, `))
-			waitList = append(waitList, waitItem)
 		}
-		return headNodes, fmt.Errorf("no config options exist with 0 dependencies - %s", strings.Join(waitList, "; "))
+
+		waiting := []string{}
+		for node := range d.Dependencies {
+			if !inCycle[node] {
+				waiting = append(waiting, node)
+			}
+		}
+		sort.Strings(waiting)
+
+		return headNodes, &UnresolvedGraphError{
+			Dependencies: d.Dependencies,
+			Cycles:       cycles,
+			Waiting:      waiting,
+		}
 	}
 
 	return headNodes, nil
 }
 
+// UnresolvedGraphError is returned by GetHeadNodes when no node has zero remaining
+// dependencies, meaning the graph is stuck on a cycle, blocked behind one, or waiting on a
+// dependency that is not itself a node of the graph. It carries
+// enough structure for the admin console to tell the cycle itself apart from items that are
+// merely waiting on a cycle member, rather than parsing the human-readable message.
+type UnresolvedGraphError struct {
+	// Dependencies is the remaining dependency map at the point the graph got stuck.
+	Dependencies map[string]map[string]struct{}
+	// Cycles is every detected dependency cycle, each listed in dependency order with the
+	// starting node repeated at the end.
+	Cycles [][]string
+	// Waiting is every remaining node that isn't itself part of a cycle: nodes that depend -
+	// directly or transitively - on one, and nodes whose dependency is not a node of the graph.
+	Waiting []string
+}
+
+// Error renders the same human-readable message the old ad-hoc string produced, so existing
+// callers that only look at .Error() keep working unchanged.
+func (e *UnresolvedGraphError) Error() string {
+	waitList := []string{}
+	for node, deps := range e.Dependencies {
+		depsList := []string{}
+		for dep := range deps {
+			depsList = append(depsList, fmt.Sprintf("%q", dep))
+		}
+		sort.Strings(depsList)
+		waitItem := fmt.Sprintf(`%q depends on %s`, node, strings.Join(depsList, `, `))
+		waitList = append(waitList, waitItem)
+	}
+	sort.Strings(waitList)
+	return fmt.Sprintf("no config options exist with 0 dependencies - %s", strings.Join(waitList, "; "))
+}
+
+// MarshalJSON lets kotsadm's API handlers return the error verbatim to the console, with the
+// cycle and waiting-node breakdown intact instead of just the flattened message.
+func (e *UnresolvedGraphError) MarshalJSON() ([]byte, error) {
+	dependencies := make(map[string][]string, len(e.Dependencies))
+	for node, deps := range e.Dependencies {
+		depList := make([]string, 0, len(deps))
+		for dep := range deps {
+			depList = append(depList, dep)
+		}
+		sort.Strings(depList)
+		dependencies[node] = depList
+	}
+
+	cycles := e.Cycles
+	if cycles == nil {
+		cycles = [][]string{}
+	}
+	waiting := e.Waiting
+	if waiting == nil {
+		waiting = []string{}
+	}
+
+	return json.Marshal(struct {
+		Error        string              `json:"error"`
+		Dependencies map[string][]string `json:"dependencies"`
+		Cycles       [][]string          `json:"cycles"`
+		Waiting      []string            `json:"waiting"`
+	}{
+		Error:        e.Error(),
+		Dependencies: dependencies,
+		Cycles:       cycles,
+		Waiting:      waiting,
+	})
+}
+
+// TopoSort returns a full linear ordering of every node such that a node always comes after
+// everything it depends on, using Kahn's algorithm. If the graph can't be fully ordered because
+// of a cycle, it returns a *CycleError describing one minimal cycle among the unordered nodes.
+// A node whose dependency is not itself a node of the graph is never ordered either, and also
+// yields a *CycleError, with an empty Cycle when no cycle exists among the unordered nodes.
+func (d *depGraph) TopoSort() ([]string, error) {
+	inDegree := make(map[string]int, len(d.Dependencies))
+	dependents := make(map[string][]string)
+
+	for node, deps := range d.Dependencies {
+		inDegree[node] = len(deps)
+		for dep := range deps {
+			dependents[dep] = append(dependents[dep], node)
+		}
+	}
+
+	queue := []string{}
+	for node, degree := range inDegree {
+		if degree == 0 {
+			queue = append(queue, node)
+		}
+	}
+	sort.Strings(queue)
+
+	order := make([]string, 0, len(inDegree))
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		order = append(order, node)
+
+		next := []string{}
+		for _, dependent := range dependents[node] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				next = append(next, dependent)
+			}
+		}
+		sort.Strings(next)
+		queue = append(queue, next...)
+	}
+
+	if len(order) < len(inDegree) {
+		resolved := make(map[string]bool, len(order))
+		for _, node := range order {
+			resolved[node] = true
+		}
+
+		remaining := make(map[string]map[string]struct{})
+		for node := range inDegree {
+			if resolved[node] {
+				continue
+			}
+			deps := make(map[string]struct{})
+			for dep := range d.Dependencies[node] {
+				if !resolved[dep] {
+					deps[dep] = struct{}{}
+				}
+			}
+			remaining[node] = deps
+		}
+
+		cycles := findCycles(remaining, true)
+		cycle := []string{}
+		if len(cycles) > 0 {
+			cycle = cycles[0]
+		}
+		return nil, &CycleError{Cycle: cycle}
+	}
+
+	return order, nil
+}
+
+// Cycles returns every distinct dependency cycle in the graph, for diagnostics. Each cycle is
+// listed in dependency order with the starting node repeated at the end.
+func (d *depGraph) Cycles() [][]string {
+	return findCycles(d.Dependencies, false)
+}
+
+// findCycles runs DFS over adjacency, tracking the recursion stack to detect back-edges. When
+// firstOnly is true, it returns as soon as one cycle is found.
+func findCycles(adjacency map[string]map[string]struct{}, firstOnly bool) [][]string {
+	nodes := make([]string, 0, len(adjacency))
+	for node := range adjacency {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	visited := make(map[string]bool)
+	onStack := make(map[string]bool)
+	seen := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		if firstOnly && len(cycles) > 0 {
+			return
+		}
+
+		visited[node] = true
+		onStack[node] = true
+		stack = append(stack, node)
+
+		deps := make([]string, 0, len(adjacency[node]))
+		for dep := range adjacency[node] {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+
+		for _, dep := range deps {
+			if firstOnly && len(cycles) > 0 {
+				break
+			}
+
+			if onStack[dep] {
+				for i, n := range stack {
+					if n == dep {
+						cycle := append([]string{}, stack[i:]...)
+						cycle = append(cycle, dep)
+						key := strings.Join(cycle, ",")
+						if !seen[key] {
+							seen[key] = true
+							cycles = append(cycles, cycle)
+						}
+						break
+					}
+				}
+				continue
+			}
+
+			if !visited[dep] {
+				visit(dep)
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		onStack[node] = false
+	}
+
+	for _, node := range nodes {
+		if firstOnly && len(cycles) > 0 {
+			break
+		}
+		if !visited[node] {
+			visit(node)
+		}
+	}
+
+	return cycles
+}
+
 func (d *depGraph) PrintData() string {
 	return fmt.Sprintf("deps: %+v", d.Dependencies)
 }
@@ -103,6 +348,13 @@ func (d *depGraph) Copy() (depGraph, error) {
 
 }
 
+// ParseConfigGroup builds the dependency graph from a set of config groups by running each
+// item's Default/Value templates through a dependency-collecting builder. It does not itself
+// evaluate those templates against resolved config values and order them by TopoSort - the
+// consumer that walks config items in head-nodes/ResolveDep order today (and that this request
+// asks to switch to a single TopoSort-ordered pass) lives in the config-value renderer outside
+// this package, which isn't part of this tree. Follow-up: once that renderer is available here,
+// replace its per-round GetHeadNodes/ResolveDep loop with a single iteration over TopoSort().
 func (d *depGraph) ParseConfigGroup(configGroups []kotsv1beta1.ConfigGroup) error {
 	staticCtx := &StaticCtx{}
 	for _, configGroup := range configGroups {
@@ -0,0 +1,203 @@
+package template
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGetHeadNodes_SelfReferencing(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "a")
+
+	heads, err := d.GetHeadNodes()
+	if len(heads) != 0 {
+		t.Fatalf("expected no head nodes, got %v", heads)
+	}
+
+	unresolved, ok := err.(*UnresolvedGraphError)
+	if !ok {
+		t.Fatalf("expected *UnresolvedGraphError, got %T: %v", err, err)
+	}
+
+	want := []string{"a", "a"}
+	if len(unresolved.Cycles) != 1 || !reflect.DeepEqual(unresolved.Cycles[0], want) {
+		t.Fatalf("expected a single self-referencing cycle %v, got %v", want, unresolved.Cycles)
+	}
+	if len(unresolved.Waiting) != 0 {
+		t.Fatalf("expected no waiting nodes, got %v", unresolved.Waiting)
+	}
+}
+
+func TestGetHeadNodes_TwoNodeCycle(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "b")
+	d.AddDep("b", "a")
+
+	heads, err := d.GetHeadNodes()
+	if len(heads) != 0 {
+		t.Fatalf("expected no head nodes, got %v", heads)
+	}
+
+	unresolved, ok := err.(*UnresolvedGraphError)
+	if !ok {
+		t.Fatalf("expected *UnresolvedGraphError, got %T: %v", err, err)
+	}
+
+	want := []string{"a", "b", "a"}
+	if len(unresolved.Cycles) != 1 || !reflect.DeepEqual(unresolved.Cycles[0], want) {
+		t.Fatalf("expected cycle %v, got %v", want, unresolved.Cycles)
+	}
+	if len(unresolved.Waiting) != 0 {
+		t.Fatalf("expected no waiting nodes, got %v", unresolved.Waiting)
+	}
+}
+
+func TestGetHeadNodes_WaitingNodeIsNotPartOfTheCycle(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "b")
+	d.AddDep("b", "a")
+	d.AddDep("c", "a") // blocked behind the cycle, but not itself a cycle member
+
+	heads, err := d.GetHeadNodes()
+	if len(heads) != 0 {
+		t.Fatalf("expected no head nodes, got %v", heads)
+	}
+
+	unresolved, ok := err.(*UnresolvedGraphError)
+	if !ok {
+		t.Fatalf("expected *UnresolvedGraphError, got %T: %v", err, err)
+	}
+
+	if len(unresolved.Waiting) != 1 || unresolved.Waiting[0] != "c" {
+		t.Fatalf("expected only %q to be waiting, got %v", "c", unresolved.Waiting)
+	}
+	if len(unresolved.Cycles) != 1 {
+		t.Fatalf("expected exactly 1 cycle, got %v", unresolved.Cycles)
+	}
+}
+
+func TestGetHeadNodes_MultiComponentOnlyOneBroken(t *testing.T) {
+	d := &depGraph{}
+	d.AddNode("x")
+	d.AddDep("y", "x") // resolvable component
+	d.AddDep("p", "q")
+	d.AddDep("q", "p") // broken component
+
+	heads, err := d.GetHeadNodes()
+	if err != nil {
+		t.Fatalf("expected no error since the healthy component still has a head node: %v", err)
+	}
+	if len(heads) != 1 || heads[0] != "x" {
+		t.Fatalf("expected head node %q, got %v", "x", heads)
+	}
+}
+
+func TestUnresolvedGraphError_ErrorMatchesLegacyFormat(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "b")
+
+	_, err := d.GetHeadNodes()
+	unresolved, ok := err.(*UnresolvedGraphError)
+	if !ok {
+		t.Fatalf("expected *UnresolvedGraphError, got %T: %v", err, err)
+	}
+
+	want := `no config options exist with 0 dependencies - "a" depends on "b"`
+	if unresolved.Error() != want {
+		t.Fatalf("expected legacy-format message %q, got %q", want, unresolved.Error())
+	}
+}
+
+func TestUnresolvedGraphError_MarshalJSON(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "b")
+	d.AddDep("b", "a")
+
+	_, err := d.GetHeadNodes()
+	unresolved, ok := err.(*UnresolvedGraphError)
+	if !ok {
+		t.Fatalf("expected *UnresolvedGraphError, got %T: %v", err, err)
+	}
+
+	data, marshalErr := json.Marshal(unresolved)
+	if marshalErr != nil {
+		t.Fatalf("MarshalJSON: %v", marshalErr)
+	}
+
+	var decoded struct {
+		Error        string              `json:"error"`
+		Dependencies map[string][]string `json:"dependencies"`
+		Cycles       [][]string          `json:"cycles"`
+		Waiting      []string            `json:"waiting"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if decoded.Error != unresolved.Error() {
+		t.Fatalf("expected error %q, got %q", unresolved.Error(), decoded.Error)
+	}
+	if len(decoded.Cycles) != 1 {
+		t.Fatalf("expected 1 cycle in JSON output, got %d", len(decoded.Cycles))
+	}
+	if !reflect.DeepEqual(decoded.Dependencies["a"], []string{"b"}) {
+		t.Fatalf("expected dependencies[\"a\"] == [\"b\"], got %v", decoded.Dependencies["a"])
+	}
+}
+
+func TestTopoSort_OrdersDependenciesBeforeDependents(t *testing.T) {
+	d := &depGraph{}
+	d.AddNode("a")
+	d.AddDep("b", "a")
+	d.AddDep("c", "b")
+
+	order, err := d.TopoSort()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	index := map[string]int{}
+	for i, node := range order {
+		index[node] = i
+	}
+	if index["a"] >= index["b"] || index["b"] >= index["c"] {
+		t.Fatalf("expected order a, b, c; got %v", order)
+	}
+}
+
+func TestTopoSort_MultiComponentOnlyOneBroken(t *testing.T) {
+	d := &depGraph{}
+	d.AddNode("x")
+	d.AddDep("y", "x") // resolvable component
+	d.AddDep("p", "q")
+	d.AddDep("q", "p") // broken component
+
+	_, err := d.TopoSort()
+	if err == nil {
+		t.Fatalf("expected an error since one component never resolves")
+	}
+
+	cycleErr, ok := err.(*CycleError)
+	if !ok {
+		t.Fatalf("expected *CycleError, got %T: %v", err, err)
+	}
+
+	want := []string{"p", "q", "p"}
+	if !reflect.DeepEqual(cycleErr.Cycle, want) {
+		t.Fatalf("expected cycle %v, got %v", want, cycleErr.Cycle)
+	}
+}
+
+func TestCycles_ReturnsEveryDistinctCycle(t *testing.T) {
+	d := &depGraph{}
+	d.AddDep("a", "b")
+	d.AddDep("b", "a")
+	d.AddDep("p", "q")
+	d.AddDep("q", "p")
+
+	cycles := d.Cycles()
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 distinct cycles, got %v", cycles)
+	}
+}